@@ -0,0 +1,99 @@
+package instancelogger
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Code identifies an error down to a specific condition using a
+// scope -> category -> detail taxonomy: Scope names the app or service that
+// raised the error, Category names the subsystem within it (Input, DB, Auth,
+// System, PubSub, ...), and Detail names the specific condition. Cloud
+// Logging queries can filter on scope/category via the labels attached to
+// each entry; stderr and the other sinks render the triple as a stable
+// "SCOPE/CATEGORY/DETAIL" prefix.
+type Code struct {
+	Scope    uint32
+	Category uint32
+	Detail   uint32
+}
+
+// String renders the code as a stable "scope/category/detail" triple. It
+// intentionally ignores the registry so the prefix never changes shape
+// depending on what's been registered.
+func (c Code) String() string {
+	return fmt.Sprintf("%d/%d/%d", c.Scope, c.Category, c.Detail)
+}
+
+// Name renders the code using any scope/category names registered with
+// RegisterScope/RegisterCategory, falling back to the numeric ID for
+// anything unregistered.
+func (c Code) Name() string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	scope, ok := scopeNames[c.Scope]
+	if !ok {
+		scope = fmt.Sprintf("%d", c.Scope)
+	}
+	category, ok := categoryNames[c.Category]
+	if !ok {
+		category = fmt.Sprintf("%d", c.Category)
+	}
+	return fmt.Sprintf("%s/%s/%d", scope, category, c.Detail)
+}
+
+var (
+	registryMu    sync.RWMutex
+	scopeNames    = map[uint32]string{}
+	categoryNames = map[uint32]string{}
+)
+
+// RegisterScope associates a human-readable name with a scope ID, used by
+// Code.Name(). Typically called from an init() function.
+func RegisterScope(id uint32, name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	scopeNames[id] = name
+}
+
+// RegisterCategory associates a human-readable name with a category ID, used
+// by Code.Name(). Typically called from an init() function.
+func RegisterCategory(id uint32, name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	categoryNames[id] = name
+}
+
+// CodedError pairs an error with the Code that classifies it. Use WrapError
+// to create one, and errors.As to recover the Code from an error chain.
+type CodedError struct {
+	Code Code
+	Err  error
+}
+
+// Error implements the error interface, prefixing the wrapped error with the
+// code's stable "scope/category/detail" triple.
+func (e *CodedError) Error() string {
+	return fmt.Sprintf("[%s] %v", e.Code, e.Err)
+}
+
+// Unwrap returns the wrapped error, so errors.Is/As see through CodedError.
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// WrapError classifies err with code, returning an error whose Error()
+// carries a stable "scope/category/detail" prefix.
+func WrapError(code Code, err error) error {
+	return &CodedError{Code: code, Err: err}
+}
+
+// ErrorWithCode is shorthand for il.Error(WrapError(code, err)).
+func (il *InstanceLogger) ErrorWithCode(code Code, err error) {
+	il.Error(WrapError(code, err))
+}
+
+// FatalWithCode is shorthand for il.Fatal(WrapError(code, err)).
+func (il *InstanceLogger) FatalWithCode(code Code, err error) {
+	il.Fatal(WrapError(code, err))
+}
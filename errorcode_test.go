@@ -0,0 +1,71 @@
+package instancelogger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCodeStringIsStableRegardlessOfRegistry(t *testing.T) {
+	code := Code{Scope: 1, Category: 2, Detail: 3}
+	RegisterScope(1, "billing")
+	RegisterCategory(2, "db")
+
+	if got, want := code.String(), "1/2/3"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestCodeNameUsesRegisteredNamesOrFallsBackToNumeric(t *testing.T) {
+	RegisterScope(42, "orders")
+	RegisterCategory(7, "auth")
+
+	named := Code{Scope: 42, Category: 7, Detail: 9}
+	if got, want := named.Name(), "orders/auth/9"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+
+	unregistered := Code{Scope: 9999, Category: 9998, Detail: 1}
+	if got, want := unregistered.Name(), "9999/9998/1"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+}
+
+func TestWrapErrorPrefixesAndUnwraps(t *testing.T) {
+	base := errors.New("boom")
+	code := Code{Scope: 1, Category: 2, Detail: 3}
+	wrapped := WrapError(code, base)
+
+	if got, want := wrapped.Error(), "[1/2/3] boom"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(wrapped, base) {
+		t.Error("expected errors.Is to see through CodedError to the wrapped error")
+	}
+
+	var coded *CodedError
+	if !errors.As(wrapped, &coded) {
+		t.Fatal("expected errors.As to recover the CodedError")
+	}
+	if coded.Code != code {
+		t.Errorf("recovered Code = %+v, want %+v", coded.Code, code)
+	}
+}
+
+func TestErrorWithCodeAttachesScopeAndCategoryLabels(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+
+	il.ErrorWithCode(Code{Scope: 5, Category: 6, Detail: 7}, errors.New("boom"))
+
+	entry := recvEntry(t, il)
+	if entry.Labels["scope"] != "5" || entry.Labels["category"] != "6" {
+		t.Errorf("expected scope/category labels 5/6, got %+v", entry.Labels)
+	}
+	msg, ok := entry.Payload.(ErrorMessage)
+	if !ok {
+		t.Fatalf("expected ErrorMessage payload, got %T", entry.Payload)
+	}
+	if msg.Code == nil || *msg.Code != (Code{Scope: 5, Category: 6, Detail: 7}) {
+		t.Errorf("expected ErrorMessage.Code to be set, got %+v", msg.Code)
+	}
+}
@@ -2,47 +2,89 @@ package instancelogger
 
 import (
 	"context"
-	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"os"
-	"runtime/debug"
 	"sync"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	"cloud.google.com/go/logging"
-	"google.golang.org/api/option"
 )
 
 var singleton *InstanceLogger
 
-// InstanceLogger is a general way to report errors to a google pubsub service.
-// Call New() and then Init().  Call Stop() when done.
+// InstanceLogger is a general way to report errors to one or more logging
+// backends (Stackdriver, stderr, syslog, a webhook, CloudWatch, ...).
+// Call New() with the sinks you want, then Init() to resolve instance
+// metadata. InstanceLogger implements the run.Unit lifecycle (Name/PreRun/
+// Serve/GracefulStop) so a run-group supervisor can drive it: Serve delivers
+// queued entries to the sinks as they arrive; without it, queued entries
+// reach the sinks only when GracefulStop drains the queue.
 type InstanceLogger struct {
-	errorTopicName *string
-	instanceName   *string
-	projectID      *string
-	ctx            context.Context
-	cancelFunc     context.CancelFunc
-	client         *logging.Client
-	clientOption   option.ClientOption
-	waitGroup      *sync.WaitGroup
-	logger         *logging.Logger
+	instanceName *string
+	projectID    *string
+	sinks        []Sink
+
+	queueSize   int
+	stopTimeout time.Duration
+	queue       chan Entry
+	queueMu     sync.RWMutex
+	accepting   bool
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+	served      chan struct{}
+	flushOnce   sync.Once
+	stopErr     error
+
+	minLevel   logging.Severity
+	stackTrace map[logging.Severity]bool
+
+	resource *Resource
 }
 
 // ErrorMessage represents a pubsub topic message for an error for use in json unmarshalling
 type ErrorMessage struct {
-	Error        string  `json:"error"`
-	Trace        string  `json:"trace"`
-	InstanceName *string `json:"instanceName"`
+	Error        string                 `json:"error"`
+	Trace        string                 `json:"trace,omitempty"`
+	InstanceName *string                `json:"instanceName"`
+	Code         *Code                  `json:"code,omitempty"`
+	Severity     string                 `json:"severity,omitempty"`
+	Fields       map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Option configures an InstanceLogger constructed with New() or NewSingleton().
+type Option func(*InstanceLogger)
+
+// WithSink adds a Sink that every logged error is fanned out to. Sinks are
+// logged to in the order they were added.
+func WithSink(sink Sink) Option {
+	return func(il *InstanceLogger) {
+		il.sinks = append(il.sinks, sink)
+	}
+}
+
+// WithQueueSize overrides the default size of the internal entry queue that
+// Serve drains. Once full, Error/Fatal drop the entry rather than block.
+func WithQueueSize(n int) Option {
+	return func(il *InstanceLogger) {
+		il.queueSize = n
+	}
+}
+
+// WithStopTimeout overrides how long GracefulStop waits for Serve to drain
+// the queue when its context carries no deadline of its own. It also bounds
+// how long GracefulStop waits for the sinks to flush and close.
+func WithStopTimeout(d time.Duration) Option {
+	return func(il *InstanceLogger) {
+		il.stopTimeout = d
+	}
 }
 
 // NewSingleton calls New and sets instancelogger's singleton instance to this.  Convenient if you
 // want one global instancelogger for the whole app.  Also returns it.
-func NewSingleton(clientOption option.ClientOption, waitGroup *sync.WaitGroup) *InstanceLogger {
-	singleton = New(clientOption, waitGroup)
+func NewSingleton(opts ...Option) *InstanceLogger {
+	singleton = New(opts...)
 	return singleton
 }
 
@@ -51,19 +93,42 @@ func Singleton() *InstanceLogger {
 	return singleton
 }
 
-// New creats a InstanceLogger *without a topic yet*.  Be sure to call Init()
-// if projectID is nil, attempts to find it from the instance metadata
-func New(clientOption option.ClientOption, waitGroup *sync.WaitGroup) *InstanceLogger {
-	return &InstanceLogger{
-		clientOption: clientOption,
-		waitGroup:    waitGroup,
+// defaultQueueSize is how many entries Error/Fatal will buffer for Serve to
+// drain before they start dropping entries under backpressure.
+const defaultQueueSize = 64
+
+// defaultStopTimeout bounds GracefulStop when its context has no deadline.
+const defaultStopTimeout = 10 * time.Second
+
+// New creates an InstanceLogger with no sinks configured; errors will only go
+// to Stderr via the standard log package until at least one sink is added
+// with WithSink. Be sure to call Init() to resolve instance metadata, and
+// Serve(ctx) for entries to actually reach the sinks.
+func New(opts ...Option) *InstanceLogger {
+	il := &InstanceLogger{}
+	for _, opt := range opts {
+		opt(il)
+	}
+	if il.queueSize == 0 {
+		il.queueSize = defaultQueueSize
+	}
+	if il.stopTimeout == 0 {
+		il.stopTimeout = defaultStopTimeout
 	}
+	il.queue = make(chan Entry, il.queueSize)
+	il.accepting = true
+	il.stopCh = make(chan struct{})
+	il.served = make(chan struct{})
+	return il
 }
 
-// Init actually starts publishing to a topic.  If this is not called, errors will only go to Stderr
-// If instanceName and/or projectID are nil, will have tried to use the instance metadata
-func (il *InstanceLogger) Init(errorTopicName string, optionalInstanceName *string, optionalProjectID *string) error {
-	il.errorTopicName = &errorTopicName
+// Init resolves instance metadata used to label log entries. If
+// optionalInstanceName and/or optionalProjectID are nil, it will have tried
+// to find them from the GCE instance metadata server. It also resolves the
+// Resource (zone, machine type, GKE cluster name, ...) reported by
+// Resource(); on hosts where metadata.OnGCE() is false it falls back to
+// environment variables and the OS hostname instead.
+func (il *InstanceLogger) Init(optionalInstanceName *string, optionalProjectID *string) error {
 	if optionalInstanceName != nil {
 		il.instanceName = optionalInstanceName
 	}
@@ -92,21 +157,6 @@ func (il *InstanceLogger) Init(errorTopicName string, optionalInstanceName *stri
 		}
 	}
 
-	il.ctx, il.cancelFunc = context.WithCancel(context.Background())
-
-	var client *logging.Client
-	var err error
-	if il.clientOption != nil {
-		client, err = logging.NewClient(il.ctx, *il.projectID, il.clientOption)
-	} else {
-		client, err = logging.NewClient(il.ctx, *il.projectID)
-	}
-	if err != nil {
-		return err
-	}
-	il.client = client
-	il.logger = client.Logger(errorTopicName)
-
 	if optionalInstanceName == nil {
 		foundInstanceName, err := c.InstanceName()
 		if err != nil {
@@ -118,57 +168,41 @@ func (il *InstanceLogger) Init(errorTopicName string, optionalInstanceName *stri
 		}
 	}
 
-	return nil
-}
-
-// Error tries to report to pubsub, otherwise just prints to Stderr
-func (il *InstanceLogger) Error(err error) {
-	if il.waitGroup != nil {
-		il.waitGroup.Add(1)
+	var projectID string
+	if il.projectID != nil {
+		projectID = *il.projectID
 	}
-	if il.logger == nil {
-		log.Printf("[ERROR:LOGGING-NOT-INIT'ED] %+v\n", err)
+	resource := discoverResource(c, projectID)
+	il.queueMu.Lock()
+	il.resource = resource
+	il.queueMu.Unlock()
 
-		if il.waitGroup != nil {
-			il.waitGroup.Done()
-		}
-		return
-	}
-
-	errorMsg := ErrorMessage{
-		Error:        fmt.Sprintf("%v", err),
-		Trace:        string(debug.Stack()),
-		InstanceName: il.instanceName,
-	}
+	return nil
+}
 
-	// Adds an entry to the log buffer.
-	il.logger.Log(logging.Entry{Payload: errorMsg})
-	log.Printf("[ERROR:REPORTED] %+v\n", errorMsg)
+// ProjectID returns the project ID resolved by Init, if any.
+func (il *InstanceLogger) ProjectID() *string {
+	return il.projectID
+}
 
-	if il.waitGroup != nil {
-		il.waitGroup.Done()
-	}
+// Error queues err at Error severity for delivery to every configured sink,
+// falling back to Stderr if no sinks are configured. Queued entries reach the
+// sinks while Serve is running, or when GracefulStop drains the queue; if the
+// queue is full or GracefulStop has already been called, the entry is dropped
+// and logged to Stderr instead. Entries below the configured minimum level
+// are dropped without being logged anywhere.
+func (il *InstanceLogger) Error(err error) {
+	il.logAt(logging.Error, err, "", nil)
 }
 
-// Fatal calls Err and os.Exit(1)
+// Fatal logs err at the highest severity, blocks in GracefulStop until Serve
+// has drained it (or the configured stop timeout elapses), then os.Exit(1)
 func (il *InstanceLogger) Fatal(err error) {
-	il.Error(err)
-	il.Stop()
+	il.logAt(logging.Emergency, err, "", nil)
+	il.GracefulStop(context.Background())
 	os.Exit(1)
 }
 
-// Stop Stop()s the topic and calls the cancel function if available
-func (il *InstanceLogger) Stop() {
-	// Closes the client and flushes the buffer to Stackdriver
-	if il.client != nil {
-		il.client.Close()
-		il.client = nil
-	} else if il.cancelFunc != nil {
-		il.cancelFunc()
-		il.cancelFunc = nil
-	}
-}
-
 // userAgentTransport sets the User-Agent header before calling base.
 type userAgentTransport struct {
 	userAgent string
@@ -0,0 +1,170 @@
+package instancelogger
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// SetLevel sets the minimum severity that is queued to sinks; entries below
+// it are dropped before ever reaching the queue. The default, logging.Default,
+// lets every level through.
+func (il *InstanceLogger) SetLevel(level logging.Severity) {
+	il.queueMu.Lock()
+	defer il.queueMu.Unlock()
+	il.minLevel = level
+}
+
+// SetStackTrace toggles whether entries at the given severity attach a
+// debug.Stack() trace. By default this is on for Warning and above.
+func (il *InstanceLogger) SetStackTrace(level logging.Severity, enabled bool) {
+	il.queueMu.Lock()
+	defer il.queueMu.Unlock()
+	if il.stackTrace == nil {
+		il.stackTrace = map[logging.Severity]bool{}
+	}
+	il.stackTrace[level] = enabled
+}
+
+func (il *InstanceLogger) wantsStackTrace(level logging.Severity) bool {
+	il.queueMu.RLock()
+	defer il.queueMu.RUnlock()
+	if enabled, ok := il.stackTrace[level]; ok {
+		return enabled
+	}
+	return level >= logging.Warning
+}
+
+// Debug queues err at Debug severity.
+func (il *InstanceLogger) Debug(err error) {
+	il.logAt(logging.Debug, err, "", nil)
+}
+
+// Info queues msg at Info severity.
+func (il *InstanceLogger) Info(msg string) {
+	il.logAt(logging.Info, nil, msg, nil)
+}
+
+// Warn queues err at Warning severity.
+func (il *InstanceLogger) Warn(err error) {
+	il.logAt(logging.Warning, err, "", nil)
+}
+
+// With returns a child logger that attaches key/value to everything it logs,
+// without mutating il. Chain further With calls to attach more fields.
+func (il *InstanceLogger) With(key string, value interface{}) *FieldLogger {
+	return &FieldLogger{il: il, fields: map[string]interface{}{key: value}}
+}
+
+// FieldLogger is a leveled logger that attaches a fixed set of structured
+// fields to everything it logs. Obtain one from InstanceLogger.With.
+type FieldLogger struct {
+	il     *InstanceLogger
+	fields map[string]interface{}
+}
+
+// With returns a further child logger with key/value merged in.
+func (f *FieldLogger) With(key string, value interface{}) *FieldLogger {
+	merged := make(map[string]interface{}, len(f.fields)+1)
+	for k, v := range f.fields {
+		merged[k] = v
+	}
+	merged[key] = value
+	return &FieldLogger{il: f.il, fields: merged}
+}
+
+// Debug queues err at Debug severity with this logger's fields attached.
+func (f *FieldLogger) Debug(err error) {
+	f.il.logAt(logging.Debug, err, "", f.fields)
+}
+
+// Info queues msg at Info severity with this logger's fields attached.
+func (f *FieldLogger) Info(msg string) {
+	f.il.logAt(logging.Info, nil, msg, f.fields)
+}
+
+// Warn queues err at Warning severity with this logger's fields attached.
+func (f *FieldLogger) Warn(err error) {
+	f.il.logAt(logging.Warning, err, "", f.fields)
+}
+
+// Error queues err at Error severity with this logger's fields attached.
+func (f *FieldLogger) Error(err error) {
+	f.il.logAt(logging.Error, err, "", f.fields)
+}
+
+// Fatal logs err with this logger's fields attached, flushes sinks, and
+// exits the process.
+func (f *FieldLogger) Fatal(err error) {
+	f.il.logAt(logging.Emergency, err, "", f.fields)
+	f.il.GracefulStop(context.Background())
+	os.Exit(1)
+}
+
+// logAt builds and queues an entry at the given severity. Exactly one of err
+// or msg is expected to carry the message.
+func (il *InstanceLogger) logAt(severity logging.Severity, err error, msg string, fields map[string]interface{}) {
+	il.queueMu.RLock()
+	minLevel := il.minLevel
+	il.queueMu.RUnlock()
+	if severity < minLevel {
+		return
+	}
+
+	if len(il.sinks) == 0 {
+		if err != nil {
+			log.Printf("[%s:LOGGING-NOT-INIT'ED] %+v\n", severity, err)
+		} else {
+			log.Printf("[%s:LOGGING-NOT-INIT'ED] %s\n", severity, msg)
+		}
+		return
+	}
+
+	errorMsg := ErrorMessage{
+		InstanceName: il.instanceName,
+		Severity:     severity.String(),
+		Fields:       fields,
+	}
+	if err != nil {
+		errorMsg.Error = err.Error()
+	} else {
+		errorMsg.Error = msg
+	}
+	if il.wantsStackTrace(severity) {
+		errorMsg.Trace = string(debug.Stack())
+	}
+
+	entry := Entry{Timestamp: time.Now(), Severity: severity, Resource: il.Resource().monitoredResource()}
+
+	if err != nil {
+		var coded *CodedError
+		if errors.As(err, &coded) {
+			errorMsg.Code = &coded.Code
+			entry.Labels = map[string]string{
+				"scope":    strconv.FormatUint(uint64(coded.Code.Scope), 10),
+				"category": strconv.FormatUint(uint64(coded.Code.Category), 10),
+			}
+		}
+	}
+	entry.Payload = errorMsg
+
+	il.queueMu.RLock()
+	accepting := il.accepting
+	il.queueMu.RUnlock()
+	if !accepting {
+		log.Printf("[%s:STOPPED] %+v\n", severity, errorMsg)
+		return
+	}
+
+	select {
+	case il.queue <- entry:
+	default:
+		log.Printf("[%s:QUEUE-FULL] %+v\n", severity, errorMsg)
+	}
+}
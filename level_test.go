@@ -0,0 +1,110 @@
+package instancelogger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// recvEntry drains a single entry from il.queue, failing the test if none
+// arrives promptly. logAt only enqueues entries (Serve is what fans them out
+// to sinks), so reading the queue directly lets these tests exercise
+// logAt's filtering/field logic without a running Serve loop.
+func recvEntry(t *testing.T, il *InstanceLogger) Entry {
+	t.Helper()
+	select {
+	case e := <-il.queue:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("expected an entry to be queued")
+		return Entry{}
+	}
+}
+
+func assertQueueEmpty(t *testing.T, il *InstanceLogger) {
+	t.Helper()
+	select {
+	case e := <-il.queue:
+		t.Fatalf("expected no entry to be queued, got %+v", e)
+	default:
+	}
+}
+
+func TestSetLevelDropsEntriesBelowMinimum(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+	il.SetLevel(logging.Warning)
+
+	il.Debug(errors.New("too quiet"))
+	il.Info("still too quiet")
+	assertQueueEmpty(t, il)
+
+	il.Warn(errors.New("loud enough"))
+	entry := recvEntry(t, il)
+	if got := entry.Payload.(ErrorMessage).Error; got != "loud enough" {
+		t.Errorf("expected the Warn entry to be queued, got %q", got)
+	}
+}
+
+func TestSetStackTraceTogglesPerLevel(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+
+	il.Info("no stack by default")
+	if recvEntry(t, il).Payload.(ErrorMessage).Trace != "" {
+		t.Error("expected Info to have no trace by default")
+	}
+
+	il.Error(errors.New("stack by default"))
+	if recvEntry(t, il).Payload.(ErrorMessage).Trace == "" {
+		t.Error("expected Error to have a trace by default")
+	}
+
+	il.SetStackTrace(logging.Info, true)
+	il.SetStackTrace(logging.Error, false)
+
+	il.Info("stack now enabled")
+	if recvEntry(t, il).Payload.(ErrorMessage).Trace == "" {
+		t.Error("expected Info to have a trace once enabled")
+	}
+
+	il.Error(errors.New("stack now disabled"))
+	if recvEntry(t, il).Payload.(ErrorMessage).Trace != "" {
+		t.Error("expected Error to have no trace once disabled")
+	}
+}
+
+func TestWithAttachesFieldsAndChains(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+
+	il.With("requestID", "abc123").With("userID", 42).Error(errors.New("boom"))
+
+	fields := recvEntry(t, il).Payload.(ErrorMessage).Fields
+	if fields["requestID"] != "abc123" || fields["userID"] != 42 {
+		t.Errorf("expected both fields attached, got %+v", fields)
+	}
+
+	// The parent logger must be unaffected by the child's fields.
+	il.Error(errors.New("unrelated"))
+	if got := recvEntry(t, il).Payload.(ErrorMessage).Fields; got != nil {
+		t.Errorf("expected parent logger to have no fields, got %+v", got)
+	}
+}
+
+func TestInfoUsesMessageNotError(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+
+	il.Info("everything is fine")
+
+	msg := recvEntry(t, il).Payload.(ErrorMessage)
+	if msg.Error != "everything is fine" {
+		t.Errorf("expected Info's message to land in ErrorMessage.Error, got %q", msg.Error)
+	}
+	if msg.Severity != logging.Info.String() {
+		t.Errorf("expected severity %q, got %q", logging.Info.String(), msg.Severity)
+	}
+}
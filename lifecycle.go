@@ -0,0 +1,121 @@
+package instancelogger
+
+import "context"
+
+// Name implements run.Unit, identifying this component to a lifecycle
+// supervisor.
+func (il *InstanceLogger) Name() string {
+	return "instancelogger"
+}
+
+// PreRun implements run.Unit. Sinks are constructed by the caller and handed
+// to New(), so there is nothing that can fail here.
+func (il *InstanceLogger) PreRun() error {
+	return nil
+}
+
+// Serve implements run.Unit. It drains the internal entry queue, delivering
+// each entry to every configured sink, until ctx is cancelled or
+// GracefulStop is called. Either way, it drains whatever is left in the
+// queue before returning, so GracefulStop can safely flush and close the
+// sinks once Serve has returned.
+func (il *InstanceLogger) Serve(ctx context.Context) error {
+	defer close(il.served)
+	for {
+		select {
+		case <-ctx.Done():
+			il.drainQueue()
+			return nil
+		case <-il.stopCh:
+			il.drainQueue()
+			return nil
+		case entry := <-il.queue:
+			for _, sink := range il.sinks {
+				sink.Log(entry)
+			}
+		}
+	}
+}
+
+// drainQueue delivers every entry currently buffered in il.queue without
+// blocking for more.
+func (il *InstanceLogger) drainQueue() {
+	for {
+		select {
+		case entry := <-il.queue:
+			for _, sink := range il.sinks {
+				sink.Log(entry)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// GracefulStop implements run.Unit. It stops Error/Fatal from queuing new
+// entries, signals Serve to drain the remaining queue and return, then
+// flushes and closes every sink exactly once, however many times
+// GracefulStop itself is called. If Serve never ran (or hasn't returned
+// within ctx's deadline, or the configured stop timeout if ctx has none),
+// GracefulStop drains the queue itself rather than hang and then drop
+// whatever was buffered; it still reports that deadline as an error, since
+// Serve genuinely never finished.
+func (il *InstanceLogger) GracefulStop(ctx context.Context) error {
+	il.queueMu.Lock()
+	il.accepting = false
+	il.queueMu.Unlock()
+
+	il.stopOnce.Do(func() { close(il.stopCh) })
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, il.stopTimeout)
+		defer cancel()
+	}
+
+	var serveErr error
+	select {
+	case <-il.served:
+	case <-ctx.Done():
+		il.drainQueue()
+		serveErr = ctx.Err()
+	}
+
+	il.flushOnce.Do(func() {
+		// ctx may already be past its deadline at this point (that's
+		// exactly the serveErr case above), so flush/close get their own
+		// fresh budget instead of racing against an already-closed
+		// ctx.Done(), which would make the returned error nondeterministic.
+		flushCtx, cancel := context.WithTimeout(context.Background(), il.stopTimeout)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			var firstErr error
+			for _, sink := range il.sinks {
+				if err := sink.Flush(); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			for _, sink := range il.sinks {
+				if err := sink.Close(); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			done <- firstErr
+		}()
+
+		select {
+		case err := <-done:
+			il.stopErr = err
+		case <-flushCtx.Done():
+			il.stopErr = flushCtx.Err()
+		}
+
+		if serveErr != nil && il.stopErr == nil {
+			il.stopErr = serveErr
+		}
+	})
+
+	return il.stopErr
+}
@@ -0,0 +1,155 @@
+package instancelogger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// fakeSink records every entry it's given and whether it has been closed,
+// so tests can assert on delivery order without a real backend.
+type fakeSink struct {
+	mu     sync.Mutex
+	logged []Entry
+	closed bool
+	closes int
+}
+
+func (f *fakeSink) Log(e Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logged = append(f.logged, e)
+}
+
+func (f *fakeSink) Flush() error { return nil }
+
+func (f *fakeSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	f.closes++
+	return nil
+}
+
+func (f *fakeSink) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.logged)
+}
+
+func (f *fakeSink) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *fakeSink) closeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closes
+}
+
+func TestGracefulStopDrainsQueueBeforeClosingSinks(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	served := make(chan struct{})
+	go func() {
+		il.Serve(ctx)
+		close(served)
+	}()
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		il.Error(fmt.Errorf("boom %d", i))
+	}
+
+	if err := il.GracefulStop(context.Background()); err != nil {
+		t.Fatalf("GracefulStop returned error: %v", err)
+	}
+
+	if got := sink.count(); got != n {
+		t.Fatalf("expected %d entries delivered before Close, got %d", n, got)
+	}
+	if !sink.isClosed() {
+		t.Fatalf("expected GracefulStop to have closed the sink")
+	}
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after GracefulStop")
+	}
+}
+
+// TestFatalEntryReachesSinkBeforeGracefulStopReturns pins down the building
+// block Fatal relies on: an entry queued immediately before GracefulStop
+// must still reach the sink, since Fatal calls os.Exit right after.
+func TestFatalEntryReachesSinkBeforeGracefulStopReturns(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go il.Serve(ctx)
+
+	il.logAt(logging.Emergency, fmt.Errorf("fatal-ish"), "", nil)
+
+	if err := il.GracefulStop(context.Background()); err != nil {
+		t.Fatalf("GracefulStop returned error: %v", err)
+	}
+	if got := sink.count(); got != 1 {
+		t.Fatalf("expected the entry to reach the sink before GracefulStop returned, got %d", got)
+	}
+}
+
+// TestGracefulStopWithoutServeStillDrainsQueue covers the pre-refactor usage
+// pattern (New().Init().Error()/Fatal() with no separate Serve goroutine):
+// GracefulStop must not silently drop buffered entries just because nothing
+// ever called Serve.
+func TestGracefulStopWithoutServeStillDrainsQueue(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink), WithStopTimeout(50*time.Millisecond))
+
+	il.Error(fmt.Errorf("nobody is listening"))
+
+	if err := il.GracefulStop(context.Background()); err == nil {
+		t.Fatalf("expected GracefulStop to report ctx deadline exceeded since Serve never ran")
+	}
+	if got := sink.count(); got != 1 {
+		t.Fatalf("expected GracefulStop to drain the queue itself, got %d entries delivered", got)
+	}
+}
+
+// TestGracefulStopIsIdempotent ensures calling GracefulStop more than once
+// (e.g. a supervisor's GracefulStop followed by a later Fatal) doesn't
+// double-close the sinks.
+func TestGracefulStopIsIdempotent(t *testing.T) {
+	sink := &fakeSink{}
+	il := New(WithSink(sink))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go il.Serve(ctx)
+
+	il.Error(fmt.Errorf("boom"))
+
+	if err := il.GracefulStop(context.Background()); err != nil {
+		t.Fatalf("first GracefulStop returned error: %v", err)
+	}
+	closeCountAfterFirst := sink.closeCount()
+
+	if err := il.GracefulStop(context.Background()); err != nil {
+		t.Fatalf("second GracefulStop returned error: %v", err)
+	}
+	if got := sink.closeCount(); got != closeCountAfterFirst {
+		t.Fatalf("expected Close to run exactly once, got %d calls after a second GracefulStop", got)
+	}
+}
@@ -0,0 +1,98 @@
+package instancelogger
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+)
+
+// Resource describes the compute resource this logger is running on. It is
+// attached to outgoing entries so they show up under the right resource type
+// (gce_instance, generic_node, ...) in Cloud Logging.
+type Resource struct {
+	Type   string
+	Labels map[string]string
+}
+
+// Resource returns the resource discovered by Init, or nil if Init hasn't
+// been called yet.
+func (il *InstanceLogger) Resource() *Resource {
+	il.queueMu.RLock()
+	defer il.queueMu.RUnlock()
+	return il.resource
+}
+
+// monitoredResource converts r to the genproto type logging.Entry.Resource
+// expects, returning nil if r is nil.
+func (r *Resource) monitoredResource() *monitoredres.MonitoredResource {
+	if r == nil {
+		return nil
+	}
+	return &monitoredres.MonitoredResource{Type: r.Type, Labels: r.Labels}
+}
+
+// discoverResource probes the GCE metadata server for the attributes needed
+// to label entries as gce_instance, including the GKE cluster name when
+// running as a node in one. When metadata.OnGCE() reports we're not on GCE,
+// it falls back
+// to well-known environment variables and the OS hostname so non-GCE
+// deployments still get a usable resource.
+func discoverResource(c *metadata.Client, projectID string) *Resource {
+	if !metadata.OnGCE() {
+		return discoverResourceFromEnv(projectID)
+	}
+
+	labels := map[string]string{}
+	if projectID != "" {
+		labels["project_id"] = projectID
+	}
+
+	if zone, err := c.Zone(); err == nil {
+		labels["zone"] = zone
+	}
+	if instanceID, err := c.InstanceID(); err == nil {
+		labels["instance_id"] = instanceID
+	}
+	if machineType, err := c.Get("instance/machine-type"); err == nil {
+		labels["machine_type"] = path.Base(machineType)
+	}
+	if preemptible, err := c.Get("instance/scheduling/preemptible"); err == nil {
+		labels["preemptible"] = strings.ToLower(strings.TrimSpace(preemptible))
+	}
+
+	// Node-level metadata can only tell us which GKE cluster we're on, not the
+	// namespace_name/pod_name/container_name a true k8s_container resource
+	// requires (those only exist via the pod's Downward API), so a cluster
+	// member is still reported as gce_instance with an extra label.
+	if clusterName, err := c.InstanceAttributeValue("cluster-name"); err == nil && clusterName != "" {
+		labels["cluster_name"] = clusterName
+	}
+
+	return &Resource{Type: "gce_instance", Labels: labels}
+}
+
+// discoverResourceFromEnv resolves a best-effort Resource for non-GCE hosts,
+// e.g. Cloud Run, ECS/EC2, or bare metal.
+func discoverResourceFromEnv(projectID string) *Resource {
+	labels := map[string]string{}
+	if projectID != "" {
+		labels["project_id"] = projectID
+	}
+
+	if service := os.Getenv("K_SERVICE"); service != "" {
+		labels["service_name"] = service
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		labels["region"] = region
+	}
+	if hostname := os.Getenv("HOSTNAME"); hostname != "" {
+		labels["node_id"] = hostname
+	} else if hostname, err := os.Hostname(); err == nil {
+		labels["node_id"] = hostname
+	}
+
+	return &Resource{Type: "generic_node", Labels: labels}
+}
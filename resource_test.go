@@ -0,0 +1,97 @@
+package instancelogger
+
+import (
+	"os"
+	"testing"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+func TestDiscoverResourceFromEnv(t *testing.T) {
+	osHostname, _ := os.Hostname()
+
+	tests := []struct {
+		name      string
+		projectID string
+		env       map[string]string
+		want      map[string]string
+	}{
+		{
+			name:      "all known vars set",
+			projectID: "my-project",
+			env:       map[string]string{"K_SERVICE": "api", "AWS_REGION": "us-east-1", "HOSTNAME": "host-1"},
+			want: map[string]string{
+				"project_id":   "my-project",
+				"service_name": "api",
+				"region":       "us-east-1",
+				"node_id":      "host-1",
+			},
+		},
+		{
+			name:      "empty project ID is omitted, not blank",
+			projectID: "",
+			env:       map[string]string{"HOSTNAME": "host-1"},
+			want:      map[string]string{"node_id": "host-1"},
+		},
+		{
+			name:      "falls back to os.Hostname when HOSTNAME unset",
+			projectID: "",
+			env:       map[string]string{},
+			want:      map[string]string{"node_id": osHostname},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"K_SERVICE", "AWS_REGION", "HOSTNAME"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got := discoverResourceFromEnv(tt.projectID)
+			if got.Type != "generic_node" {
+				t.Errorf("Type = %q, want %q", got.Type, "generic_node")
+			}
+			for k, v := range tt.want {
+				if got.Labels[k] != v {
+					t.Errorf("Labels[%q] = %q, want %q", k, got.Labels[k], v)
+				}
+			}
+			if _, ok := tt.want["project_id"]; !ok {
+				if _, present := got.Labels["project_id"]; present {
+					t.Errorf("expected no project_id label, got %q", got.Labels["project_id"])
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoverResourceFallsBackOffGCE(t *testing.T) {
+	// Off GCE, discoverResource takes the non-GCE branch; this pins that
+	// routing decision down.
+	if metadata.OnGCE() {
+		t.Skip("running on GCE; discoverResource would take the metadata-server branch")
+	}
+
+	t.Setenv("K_SERVICE", "my-service")
+	got := discoverResource(metadata.NewClient(nil), "my-project")
+
+	if got.Type != "generic_node" {
+		t.Errorf("Type = %q, want %q", got.Type, "generic_node")
+	}
+	if got.Labels["service_name"] != "my-service" {
+		t.Errorf("expected service_name label from K_SERVICE, got %+v", got.Labels)
+	}
+}
+
+func TestResourceMonitoredResourceIsNilSafe(t *testing.T) {
+	var r *Resource
+	if got := r.monitoredResource(); got != nil {
+		t.Errorf("expected nil MonitoredResource for a nil Resource, got %+v", got)
+	}
+
+	r = &Resource{Type: "gce_instance", Labels: map[string]string{"zone": "us-central1-a"}}
+	mr := r.monitoredResource()
+	if mr.Type != "gce_instance" || mr.Labels["zone"] != "us-central1-a" {
+		t.Errorf("unexpected MonitoredResource: %+v", mr)
+	}
+}
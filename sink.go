@@ -0,0 +1,258 @@
+package instancelogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/rs/zerolog"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+)
+
+// Entry is the payload handed to every configured Sink.  It carries a subset
+// of logging.Entry's fields; its Severity is still a logging.Severity.
+type Entry struct {
+	Payload   interface{}
+	Timestamp time.Time
+	Severity  logging.Severity
+	// Labels are attached to the Stackdriver entry verbatim, so e.g. a Code's
+	// scope/category can be queried on without parsing Payload.
+	Labels map[string]string
+	// Resource identifies the MonitoredResource (gce_instance or generic_node)
+	// this entry was generated on, if known. GKE nodes are reported as
+	// gce_instance; k8s_container is never set.
+	Resource *monitoredres.MonitoredResource
+}
+
+// Sink is a destination for log entries.  InstanceLogger fans every logged
+// entry out to all configured sinks, so an app can keep working (and keep
+// structured logs) even when it isn't running on GCE.
+type Sink interface {
+	// Log hands off a single entry.  Implementations should not block longer
+	// than necessary; slow sinks should buffer internally.
+	Log(Entry)
+	// Flush blocks until any buffered entries have been written.
+	Flush() error
+	// Close flushes and releases any resources held by the sink.
+	Close() error
+}
+
+// StackdriverSink writes entries to Google Cloud Logging.  It is the original
+// backend of InstanceLogger; like every sink, it must be added with WithSink.
+type StackdriverSink struct {
+	client *logging.Client
+	logger *logging.Logger
+}
+
+// NewStackdriverSink dials Cloud Logging for projectID and returns a Sink that
+// logs to logID.  clientOption may be nil to use the default credentials.
+func NewStackdriverSink(ctx context.Context, projectID, logID string, clientOption option.ClientOption) (*StackdriverSink, error) {
+	var client *logging.Client
+	var err error
+	if clientOption != nil {
+		client, err = logging.NewClient(ctx, projectID, clientOption)
+	} else {
+		client, err = logging.NewClient(ctx, projectID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &StackdriverSink{
+		client: client,
+		logger: client.Logger(logID),
+	}, nil
+}
+
+// Log adds an entry to the Stackdriver buffer.
+func (s *StackdriverSink) Log(e Entry) {
+	s.logger.Log(logging.Entry{
+		Payload:   e.Payload,
+		Timestamp: e.Timestamp,
+		Labels:    e.Labels,
+		Severity:  e.Severity,
+		Resource:  e.Resource,
+	})
+}
+
+// Flush blocks until the Stackdriver buffer has been sent.
+func (s *StackdriverSink) Flush() error {
+	return s.logger.Flush()
+}
+
+// Close flushes the buffer and closes the underlying client.
+func (s *StackdriverSink) Close() error {
+	return s.client.Close()
+}
+
+// StderrSink writes structured entries to stderr via zerolog.  Useful as a
+// fallback, or as the only sink when running off of GCE entirely.
+type StderrSink struct {
+	logger zerolog.Logger
+}
+
+// NewStderrSink returns a Sink that writes JSON log lines to stderr.
+func NewStderrSink() *StderrSink {
+	return newStderrSink(os.Stderr)
+}
+
+// newStderrSink builds a StderrSink around an arbitrary writer, so tests can
+// assert on its output without capturing the real os.Stderr.
+func newStderrSink(w io.Writer) *StderrSink {
+	return &StderrSink{
+		logger: zerolog.New(w).With().Timestamp().Logger(),
+	}
+}
+
+// Log writes the entry to stderr at its severity.
+func (s *StderrSink) Log(e Entry) {
+	var event *zerolog.Event
+	switch {
+	case e.Severity >= logging.Error:
+		event = s.logger.Error()
+	case e.Severity >= logging.Warning:
+		event = s.logger.Warn()
+	case e.Severity >= logging.Info:
+		event = s.logger.Info()
+	default:
+		event = s.logger.Debug()
+	}
+	event.Interface("payload", e.Payload).Msg("")
+}
+
+// Flush is a no-op; zerolog's stderr writer is unbuffered.
+func (s *StderrSink) Flush() error { return nil }
+
+// Close is a no-op; there is nothing to release.
+func (s *StderrSink) Close() error { return nil }
+
+// SyslogSink writes entries to a local or remote syslog daemon.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogSink dials syslog over network/raddr (raddr == "" dials the local
+// daemon) and tags entries with tag.
+func NewSyslogSink(network, raddr, tag string) (*SyslogSink, error) {
+	writer, err := syslog.Dial(network, raddr, syslog.LOG_ERR|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: writer}, nil
+}
+
+// Log writes the entry to syslog at a priority matching its severity.
+func (s *SyslogSink) Log(e Entry) {
+	msg := fmt.Sprintf("%+v", e.Payload)
+	switch {
+	case e.Severity >= logging.Error:
+		s.writer.Err(msg)
+	case e.Severity >= logging.Warning:
+		s.writer.Warning(msg)
+	case e.Severity >= logging.Info:
+		s.writer.Info(msg)
+	default:
+		s.writer.Debug(msg)
+	}
+}
+
+// Flush is a no-op; the syslog writer is unbuffered.
+func (s *SyslogSink) Flush() error { return nil }
+
+// Close closes the connection to the syslog daemon.
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
+
+// HTTPSink POSTs each entry's Payload as JSON to a webhook URL.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink returns a Sink that POSTs each entry's Payload as JSON to url.
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Log POSTs the entry's Payload to the configured webhook URL. Delivery
+// errors are discarded; the client's 5-second timeout bounds how long a slow
+// webhook can block the call.
+func (s *HTTPSink) Log(e Entry) {
+	body, err := json.Marshal(e.Payload)
+	if err != nil {
+		return
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// Flush is a no-op; each Log call already delivers synchronously.
+func (s *HTTPSink) Flush() error { return nil }
+
+// Close is a no-op; the http.Client has nothing to release.
+func (s *HTTPSink) Close() error { return nil }
+
+// CloudWatchSink writes entries to an AWS CloudWatch Logs log stream.
+type CloudWatchSink struct {
+	client        *cloudwatchlogs.Client
+	logGroup      string
+	logStream     string
+	sequenceToken *string
+}
+
+// NewCloudWatchSink returns a Sink that writes to logGroup/logStream using
+// cfg for AWS credentials and region. The log group and stream must already
+// exist.
+func NewCloudWatchSink(cfg aws.Config, logGroup, logStream string) *CloudWatchSink {
+	return &CloudWatchSink{
+		client:    cloudwatchlogs.NewFromConfig(cfg),
+		logGroup:  logGroup,
+		logStream: logStream,
+	}
+}
+
+// Log puts the entry onto the CloudWatch log stream.
+func (s *CloudWatchSink) Log(e Entry) {
+	body, err := json.Marshal(e.Payload)
+	if err != nil {
+		return
+	}
+	out, err := s.client.PutLogEvents(context.Background(), &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(s.logGroup),
+		LogStreamName: aws.String(s.logStream),
+		SequenceToken: s.sequenceToken,
+		LogEvents: []types.InputLogEvent{
+			{
+				Message:   aws.String(string(body)),
+				Timestamp: aws.Int64(e.Timestamp.UnixMilli()),
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+	s.sequenceToken = out.NextSequenceToken
+}
+
+// Flush is a no-op; PutLogEvents already delivers synchronously.
+func (s *CloudWatchSink) Flush() error { return nil }
+
+// Close is a no-op; the CloudWatch client has nothing to release.
+func (s *CloudWatchSink) Close() error { return nil }
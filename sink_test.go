@@ -0,0 +1,89 @@
+package instancelogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+func TestStderrSinkWritesPayloadAtMatchingLevel(t *testing.T) {
+	tests := []struct {
+		severity logging.Severity
+		want     string
+	}{
+		{logging.Debug, `"level":"debug"`},
+		{logging.Info, `"level":"info"`},
+		{logging.Warning, `"level":"warn"`},
+		{logging.Error, `"level":"error"`},
+		{logging.Emergency, `"level":"error"`},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		sink := newStderrSink(&buf)
+		sink.Log(Entry{Payload: ErrorMessage{Error: "boom"}, Severity: tt.severity})
+
+		got := buf.String()
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("severity %v: expected output to contain %q, got %q", tt.severity, tt.want, got)
+		}
+		if !strings.Contains(got, `"error":"boom"`) {
+			t.Errorf("severity %v: expected payload to be present, got %q", tt.severity, got)
+		}
+	}
+}
+
+func TestHTTPSinkPostsEntryAsJSON(t *testing.T) {
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL)
+	sink.Log(Entry{Payload: ErrorMessage{Error: "boom"}, Timestamp: time.Now()})
+
+	select {
+	case body := <-received:
+		var msg ErrorMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("failed to unmarshal posted body: %v", err)
+		}
+		if msg.Error != "boom" {
+			t.Errorf("expected Error %q, got %q", "boom", msg.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook did not receive a request")
+	}
+}
+
+func TestFanOutDeliversToEverySink(t *testing.T) {
+	a, b := &fakeSink{}, &fakeSink{}
+	il := New(WithSink(a), WithSink(b))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go il.Serve(ctx)
+
+	il.logAt(logging.Error, nil, "fan out", nil)
+
+	if err := il.GracefulStop(context.Background()); err != nil {
+		t.Fatalf("GracefulStop returned error: %v", err)
+	}
+
+	if got := a.count(); got != 1 {
+		t.Errorf("expected sink a to receive 1 entry, got %d", got)
+	}
+	if got := b.count(); got != 1 {
+		t.Errorf("expected sink b to receive 1 entry, got %d", got)
+	}
+}